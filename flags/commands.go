@@ -0,0 +1,208 @@
+package flags
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// commandNode is one level of the command tree built by buildCommandTree.
+// The root node (path "") holds the schema's top-level flags; every struct
+// field found while walking the schema becomes a child node keyed by its
+// (lowercased) field name, addressable by its dotted path, e.g. the `Add`
+// struct nested inside `Remote` becomes the node at path "remote.add".
+type commandNode struct {
+	path     string
+	fs       *flag.FlagSet
+	value    reflect.Value
+	fields   []fieldBinding
+	children map[string]*commandNode
+}
+
+// fieldBinding remembers which struct field a flag was generated for, so
+// that once its flag.FlagSet has parsed argv we can look the field back up
+// again by name and assign the parsed value onto it.
+type fieldBinding struct {
+	field reflect.Value
+	sf    reflect.StructField
+}
+
+// scratchCopy returns an addressable shallow copy of v, so callers that only
+// want to inspect the schema (PrintUsage, GenerateCompletion) can walk it
+// with buildCommandTree without IterFields' nil-pointer allocation (needed
+// so Parse can populate nested commands) leaking back onto the caller's own
+// struct.
+func scratchCopy(v reflect.Value) reflect.Value {
+	scratch := reflect.New(v.Type()).Elem()
+	scratch.Set(v)
+	return scratch
+}
+
+// buildCommandTree walks v (the schema the caller passed to Parse) and
+// returns every commandNode discovered, keyed by dotted path. It is built
+// fresh on every call so that running Parse more than once (table-driven
+// tests, long-lived processes re-parsing argv) never trips a "flag
+// redefined" panic from a previous call's flags still being registered.
+func buildCommandTree(v reflect.Value) map[string]*commandNode {
+	tree := make(map[string]*commandNode)
+
+	root := &commandNode{
+		fs:       flag.NewFlagSet(os.Args[0], flag.ExitOnError),
+		value:    v,
+		children: make(map[string]*commandNode),
+	}
+	root.fs.Usage = func() { renderUsage(root.fs.Output(), root) }
+	tree[""] = root
+
+	IterFields(root, tree, make(map[reflect.Type]bool))
+
+	return tree
+}
+
+// IterFields walks the fields of node.value, including nested structs at any
+// depth, registering a flag for every leaf field and a child commandNode
+// (with its own *flag.FlagSet) for every struct field.
+//
+// seen guards against a self-referential schema, e.g.
+//
+//	type Node struct {
+//	    Sub *Node
+//	}
+//
+// recursing into the same struct type while it's already an ancestor of the
+// field being visited would otherwise walk forever; seen tracks the types
+// currently on the path from the root to the field being visited, and is
+// cleared again once that branch finishes, so the same type can still
+// appear more than once in unrelated branches of the schema.
+func IterFields(node *commandNode, tree map[string]*commandNode, seen map[reflect.Type]bool) {
+	st := node.value.Type()
+
+	for i := 0; i < node.value.NumField(); i++ {
+		field := node.value.Field(i)
+		sf := st.Field(i)
+
+		ft := field.Type()
+		isPtr := ft.Kind() == reflect.Ptr
+		underlying := ft
+		if isPtr {
+			underlying = ft.Elem()
+		}
+
+		if underlying.Kind() != reflect.Struct {
+			if field.CanSet() {
+				defineFlag(node.fs, field, sf)
+				node.fields = append(node.fields, fieldBinding{field, sf})
+			}
+			continue
+		}
+
+		// by convention a struct field is a nested command, not a flag.
+		//
+		if seen[underlying] {
+			continue
+		}
+
+		if !field.CanSet() {
+			continue
+		}
+
+		if isPtr && field.IsNil() {
+			field.Set(reflect.New(underlying))
+		}
+
+		childValue := field
+		if isPtr {
+			childValue = field.Elem()
+		}
+
+		name := strings.ToLower(sf.Name)
+		path := name
+		if node.path != "" {
+			path = node.path + "." + name
+		}
+
+		child := &commandNode{
+			path:     path,
+			fs:       flag.NewFlagSet(path, flag.ExitOnError),
+			value:    childValue,
+			children: make(map[string]*commandNode),
+		}
+		child.fs.Usage = func() { renderUsage(child.fs.Output(), child) }
+		node.children[name] = child
+		tree[path] = child
+
+		seen[underlying] = true
+		IterFields(child, tree, seen)
+		delete(seen, underlying)
+	}
+}
+
+// assign copies every flag actually set on node.fs back onto the matching
+// struct field.
+func assign(node *commandNode) {
+	node.fs.Visit(func(f *flag.Flag) {
+		// annoyingly you can't get to the flag's concrete value, so we have to
+		// first type assert it to a flag.Getter which then gives us an interface
+		// (e.g. Get()) for accessing the internal value which we finally can
+		// assign (via reflection) to our struct field.
+		//
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+
+		for _, fb := range node.fields {
+			if f.Name == strings.ToLower(fb.sf.Name) || f.Name == fb.sf.Tag.Get("short") {
+				assignField(fb.field, getter.Get())
+			}
+		}
+	})
+}
+
+// parseCommandChain parses args against root's *flag.FlagSet, then keeps
+// descending into whichever child command the remaining tokens name next,
+// parsing that command's own *flag.FlagSet before checking for a further
+// subcommand - so a flag attached to an intermediate command (e.g. `remote`
+// in `remote -verbose add -force`) is parsed and assigned at that level
+// instead of being silently dropped when only the deepest command's flags
+// were ever parsed. It returns every node visited, root first.
+func parseCommandChain(root *commandNode, args []string) ([]*commandNode, error) {
+	chain := []*commandNode{root}
+
+	node := root
+	if err := node.fs.Parse(args); err != nil {
+		return nil, err
+	}
+	assign(node)
+
+	remaining := node.fs.Args()
+	for len(remaining) > 0 {
+		child, ok := node.children[remaining[0]]
+		if !ok {
+			break
+		}
+
+		node = child
+		chain = append(chain, node)
+		remaining = remaining[1:]
+
+		if err := node.fs.Parse(remaining); err != nil {
+			return nil, err
+		}
+		assign(node)
+		remaining = node.fs.Args()
+	}
+
+	return chain, nil
+}
+
+// CommandFlagSet returns the *flag.FlagSet registered for the command at
+// path, or nil if no such command exists in tree.
+func CommandFlagSet(path string, tree map[string]*commandNode) *flag.FlagSet {
+	node, ok := tree[path]
+	if !ok {
+		return nil
+	}
+	return node.fs
+}
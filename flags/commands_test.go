@@ -0,0 +1,53 @@
+package flags
+
+import "testing"
+
+func TestParseAssignsIntermediateCommandFlags(t *testing.T) {
+	type Add struct {
+		Force bool `short:"f" usage:"force"`
+	}
+	type Remote struct {
+		Verbose bool `short:"v" usage:"verbose"`
+		Add     Add
+	}
+	type Schema struct {
+		Remote Remote
+	}
+
+	var s Schema
+	withArgs(t, []string{"remote", "-verbose", "add", "-force"}, func() {
+		if err := Parse(&s); err != nil {
+			t.Fatalf("Parse returned error: %v", err)
+		}
+	})
+
+	if !s.Remote.Verbose {
+		t.Error("Remote.Verbose = false, want true (flag set on an intermediate command)")
+	}
+	if !s.Remote.Add.Force {
+		t.Error("Remote.Add.Force = false, want true (flag set on the deepest command)")
+	}
+}
+
+func TestParseCommandChainStopsAtUnknownToken(t *testing.T) {
+	type Add struct {
+		Force bool `short:"f" usage:"force"`
+	}
+	type Remote struct {
+		Add Add
+	}
+	type Schema struct {
+		Remote Remote
+	}
+
+	var s Schema
+	withArgs(t, []string{"remote", "bogus", "-force"}, func() {
+		if err := Parse(&s); err != nil {
+			t.Fatalf("Parse returned error: %v", err)
+		}
+	})
+
+	if s.Remote.Add.Force {
+		t.Error("Remote.Add.Force = true, want false (args never reached the add command)")
+	}
+}
@@ -0,0 +1,185 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GenerateCompletion renders a shell completion script for s's schema. The
+// commands and flags offered are discovered with the same buildCommandTree
+// walk Parse uses, so there's no separate completion spec to keep in sync -
+// add a field or nested command struct and the next generated script picks
+// it up. shell must be one of "bash", "zsh", or "fish".
+func GenerateCompletion(shell string, s interface{}) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return "", ErrWrongType
+	}
+
+	tree := buildCommandTree(scratchCopy(v))
+	prog := filepath.Base(os.Args[0])
+
+	switch strings.ToLower(shell) {
+	case "bash":
+		return bashCompletion(prog, tree), nil
+	case "zsh":
+		return zshCompletion(prog, tree), nil
+	case "fish":
+		return fishCompletion(prog, tree), nil
+	default:
+		return "", fmt.Errorf("flags: unsupported shell %q (expected bash, zsh, or fish)", shell)
+	}
+}
+
+// sortedPaths returns every path in tree (including the root path, ""),
+// sorted for stable output.
+func sortedPaths(tree map[string]*commandNode) []string {
+	paths := make([]string, 0, len(tree))
+	for path := range tree {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// childNames returns node's immediate subcommand names, sorted.
+func childNames(node *commandNode) []string {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nodeFlags returns node's own long and short flag names (deduplicated,
+// sorted), each already prefixed with the right number of dashes.
+func nodeFlags(node *commandNode) []string {
+	seen := make(map[string]bool)
+	for _, fb := range node.fields {
+		seen["--"+strings.ToLower(fb.sf.Name)] = true
+		if short := fb.sf.Tag.Get("short"); short != "" {
+			seen["-"+short] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bashCompletion generates a completion function that walks COMP_WORDS
+// against the schema's command tree to find which level we're currently
+// completing at, then offers that level's subcommands and flags. A flat
+// `compgen -W "remote add ..."` can't represent "remote add" as a single
+// two-word candidate, so nested commands need this level-aware walk rather
+// than one static word list.
+// bashKey maps a dotted command path to its associative-array key. Bash
+// rejects "" as an array subscript, so the root path is stored under the
+// sentinel "ROOT" instead (a key no real command name can collide with,
+// since command names are lowercased from Go identifiers and "ROOT" isn't
+// a valid exported field name once lowercased... well, it is, so this is
+// merely "vanishingly unlikely" rather than impossible).
+func bashKey(path string) string {
+	if path == "" {
+		return "ROOT"
+	}
+	return path
+}
+
+func bashCompletion(prog string, tree map[string]*commandNode) string {
+	var commands, flags strings.Builder
+	for _, path := range sortedPaths(tree) {
+		node := tree[path]
+		fmt.Fprintf(&commands, "        [%s]=%q\n", bashKey(path), strings.Join(childNames(node), " "))
+		fmt.Fprintf(&flags, "        [%s]=%q\n", bashKey(path), strings.Join(nodeFlags(node), " "))
+	}
+
+	return fmt.Sprintf(`_%[1]s_completions()
+{
+    local -A _commands=(
+%[2]s    )
+    local -A _flags=(
+%[3]s    )
+
+    local cur path="" key i w c matched
+    cur="${COMP_WORDS[COMP_CWORD]}"
+
+    for (( i=1; i<COMP_CWORD; i++ )); do
+        w="${COMP_WORDS[i]}"
+        [[ "$w" == -* ]] && continue
+        key="${path:-ROOT}"
+        matched=""
+        for c in ${_commands[$key]}; do
+            [[ "$c" == "$w" ]] && matched="$c" && break
+        done
+        [[ -n "$matched" ]] && path="${path:+$path.}$matched"
+    done
+
+    key="${path:-ROOT}"
+    COMPREPLY=( $(compgen -W "${_commands[$key]} ${_flags[$key]}" -- "$cur") )
+}
+complete -F _%[1]s_completions %[1]s
+`, prog, commands.String(), flags.String())
+}
+
+// zshCompletion reuses the bash completion function via zsh's bashcompinit
+// compatibility layer, rather than hand-rolling an equivalent zsh-native
+// walk of the command tree.
+func zshCompletion(prog string, tree map[string]*commandNode) string {
+	return fmt.Sprintf("#compdef %[1]s\n\nautoload -U +X bashcompinit && bashcompinit\n%[2]s", prog, bashCompletion(prog, tree))
+}
+
+// fishCondition returns the `complete -n` predicate for path: only offer
+// this node's commands/flags once every command token leading to it has
+// actually been typed.
+func fishCondition(path string) string {
+	if path == "" {
+		return "__fish_use_subcommand"
+	}
+
+	parts := strings.Split(path, ".")
+	conds := make([]string, len(parts))
+	for i, p := range parts {
+		conds[i] = "__fish_seen_subcommand_from " + p
+	}
+	return strings.Join(conds, "; and ")
+}
+
+func fishCompletion(prog string, tree map[string]*commandNode) string {
+	var b strings.Builder
+
+	for _, path := range sortedPaths(tree) {
+		node := tree[path]
+		cond := fishCondition(path)
+
+		for _, name := range childNames(node) {
+			fmt.Fprintf(&b, "complete -c %s -n %q -a %s\n", prog, cond, name)
+		}
+
+		condArg := ""
+		if path != "" {
+			condArg = fmt.Sprintf("-n %q ", cond)
+		}
+
+		for _, fb := range node.fields {
+			name := strings.ToLower(fb.sf.Name)
+			usage := fb.sf.Tag.Get("usage")
+
+			if short := fb.sf.Tag.Get("short"); short != "" {
+				fmt.Fprintf(&b, "complete -c %s %s-l %s -s %s -d %q\n", prog, condArg, name, short, usage)
+				continue
+			}
+			fmt.Fprintf(&b, "complete -c %s %s-l %s -d %q\n", prog, condArg, name, usage)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package flags
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Option configures the behaviour of ParseWithOptions.
+type Option func(*options)
+
+type options struct {
+	envPrefix    string
+	configPath   string
+	configFormat string
+	useDefaults  bool
+}
+
+// WithEnvPrefix makes every field with an `env:"FOO"` tag also resolvable
+// from the environment variable prefix+"FOO" (e.g. prefix "MYAPP_" plus
+// `env:"TIMEOUT"` looks up MYAPP_TIMEOUT).
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) { o.envPrefix = prefix }
+}
+
+// WithConfigFile loads path as a config file in the given format and makes
+// its keys available as a fallback source for fields not set via flag or
+// environment variable.
+//
+// format is restricted to "json" by design, not as a stopgap: this package
+// takes no third-party dependencies, and a hand-rolled YAML/TOML decoder
+// that only understood flat "key: value" lines would silently misparse
+// section headers, lists, and nested maps rather than reporting an error.
+// Any other format is rejected up front so that a config file is either
+// parsed correctly or not read at all - never silently misread.
+func WithConfigFile(path, format string) Option {
+	return func(o *options) {
+		o.configPath = path
+		o.configFormat = format
+	}
+}
+
+// WithDefaults enables falling back to a field's `default:"..."` tag once
+// flag, env, and config file have all failed to produce a value.
+func WithDefaults() Option {
+	return func(o *options) { o.useDefaults = true }
+}
+
+// ParseWithOptions behaves like Parse, except that a field left unset on
+// the command line is resolved, in order, from: an environment variable
+// (if WithEnvPrefix and an `env` tag are present), a JSON config file (if
+// WithConfigFile is set - see its doc comment for why only JSON is
+// supported), and finally a `default:"..."` tag (if WithDefaults is set).
+// A field that no source provides a value for keeps its zero value, same
+// as Parse.
+//
+// The returned Source map records, per dotted field path, which of
+// "flag", "env", "config", "default", or "zero" supplied the field's final
+// value - useful for debugging precedence or printing an effective config.
+func ParseWithOptions(s interface{}, opts ...Option) (map[string]string, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// unlike Parse, zero CLI args is not an error here: the whole point of
+	// WithEnvPrefix/WithConfigFile/WithDefaults is to let a schema be filled
+	// in without any flags at all.
+	//
+	args := os.Args[1:]
+
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrWrongType
+	}
+
+	if err := Validate(s); err != nil {
+		return nil, err
+	}
+
+	tree := buildCommandTree(v)
+
+	chain, err := parseCommandChain(tree[""], args)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg map[string]string
+	if o.configPath != "" {
+		cfg, err = loadConfig(o.configPath, o.configFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	source := make(map[string]string)
+	for _, node := range chain {
+		if err := resolve(node, cfg, o, source); err != nil {
+			return nil, err
+		}
+	}
+
+	return source, nil
+}
+
+// resolve fills in, for every field on node that no flag was supplied for,
+// a value from env, then config, then default - recording the winning
+// source (or "zero" if none applied) into source, keyed by the field's
+// dotted path. A value found in the config file that fails to parse is
+// reported as an error rather than silently falling through to the next
+// source, since that would make a typo'd or corrupted config entry look
+// identical to one that was simply absent.
+func resolve(node *commandNode, cfg map[string]string, o *options, source map[string]string) error {
+	setByFlag := make(map[string]bool)
+	node.fs.Visit(func(f *flag.Flag) { setByFlag[f.Name] = true })
+
+	for _, fb := range node.fields {
+		name := strings.ToLower(fb.sf.Name)
+		short := fb.sf.Tag.Get("short")
+
+		path := name
+		if node.path != "" {
+			path = node.path + "." + name
+		}
+
+		if setByFlag[name] || (short != "" && setByFlag[short]) {
+			source[path] = "flag"
+			continue
+		}
+
+		if env := fb.sf.Tag.Get("env"); env != "" {
+			if val, ok := os.LookupEnv(o.envPrefix + env); ok {
+				if err := setFromString(fb.field, val); err == nil {
+					source[path] = "env"
+					continue
+				}
+			}
+		}
+
+		if cfg != nil {
+			if val, ok := cfg[path]; ok {
+				if err := setFromString(fb.field, val); err != nil {
+					return fmt.Errorf("flags: config value for %q: %v", path, err)
+				}
+				source[path] = "config"
+				continue
+			}
+		}
+
+		if o.useDefaults {
+			if def := fb.sf.Tag.Get("default"); def != "" {
+				if err := setFromString(fb.field, def); err == nil {
+					source[path] = "default"
+					continue
+				}
+			}
+		}
+
+		source[path] = "zero"
+	}
+
+	return nil
+}
+
+// setFromString parses s using the parser registered for field's type (see
+// RegisterParser) and assigns the result onto field.
+func setFromString(field reflect.Value, s string) error {
+	factory, ok := lookupParser(field.Type())
+	if !ok {
+		return fmt.Errorf("flags: no parser registered for type %s", field.Type())
+	}
+
+	value := factory()
+	if err := value.(flag.Value).Set(s); err != nil {
+		return err
+	}
+
+	assignField(field, value.Get())
+	return nil
+}
+
+// loadConfig reads path and decodes it into a flat map of dotted key ->
+// string value, e.g. {"remote": {"add": {"force": true}}} in JSON becomes
+// {"remote.add.force": "true"}.
+func loadConfig(path, format string) (map[string]string, error) {
+	if strings.ToLower(format) != "json" {
+		return nil, fmt.Errorf("flags: unsupported config format %q (only \"json\" is supported)", format)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// UseNumber keeps a JSON number as its original literal (via
+	// json.Number's String() method) instead of decoding it into a float64,
+	// which would render large integers (e.g. 1000000) in scientific
+	// notation once stringified and break strconv.Atoi/ParseInt downstream.
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("flags: %s contains more than one JSON value", path)
+	}
+
+	cfg := make(map[string]string)
+	flatten("", raw, cfg)
+	return cfg, nil
+}
+
+func flatten(prefix string, raw map[string]interface{}, out map[string]string) {
+	for k, v := range raw {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(key, nested, out)
+			continue
+		}
+
+		if num, ok := v.(json.Number); ok {
+			out[key] = num.String()
+			continue
+		}
+
+		out[key] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,147 @@
+package flags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type configTestSchema struct {
+	Timeout time.Duration `env:"TIMEOUT" usage:"timeout" default:"5s"`
+	Name    string        `env:"NAME" usage:"name" default:"fallback"`
+	Port    int           `env:"PORT" usage:"port" default:"8080"`
+}
+
+func withArgs(t *testing.T, args []string, fn func()) {
+	t.Helper()
+	orig := os.Args
+	os.Args = append([]string{"prog"}, args...)
+	defer func() { os.Args = orig }()
+	fn()
+}
+
+func TestParseWithOptionsNoArgsUsesFallbacks(t *testing.T) {
+	t.Setenv("TIMEOUT", "10s")
+
+	var s configTestSchema
+	var source map[string]string
+	var err error
+
+	withArgs(t, nil, func() {
+		source, err = ParseWithOptions(&s, WithEnvPrefix(""), WithDefaults())
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions returned error with zero args: %v", err)
+	}
+
+	if s.Timeout != 10*time.Second {
+		t.Errorf("Timeout = %v, want 10s (from env)", s.Timeout)
+	}
+	if s.Name != "fallback" {
+		t.Errorf("Name = %q, want %q (from default)", s.Name, "fallback")
+	}
+	if source["timeout"] != "env" {
+		t.Errorf("source[timeout] = %q, want %q", source["timeout"], "env")
+	}
+	if source["name"] != "default" {
+		t.Errorf("source[name] = %q, want %q", source["name"], "default")
+	}
+}
+
+func TestParseWithOptionsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "cfg.json")
+	if err := os.WriteFile(configPath, []byte(`{"timeout": "30s", "name": "from-config", "port": 9090}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TIMEOUT", "10s")
+
+	var s configTestSchema
+	var source map[string]string
+	var err error
+
+	withArgs(t, []string{"-name=from-flag"}, func() {
+		source, err = ParseWithOptions(&s,
+			WithEnvPrefix(""),
+			WithConfigFile(configPath, "json"),
+			WithDefaults(),
+		)
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions returned error: %v", err)
+	}
+
+	// flag beats everything else
+	if s.Name != "from-flag" || source["name"] != "flag" {
+		t.Errorf("Name = %q (source %q), want %q (source %q)", s.Name, source["name"], "from-flag", "flag")
+	}
+	// env beats config and default
+	if s.Timeout != 10*time.Second || source["timeout"] != "env" {
+		t.Errorf("Timeout = %v (source %q), want 10s (source env)", s.Timeout, source["timeout"])
+	}
+	// config beats default
+	if s.Port != 9090 || source["port"] != "config" {
+		t.Errorf("Port = %v (source %q), want 9090 (source config)", s.Port, source["port"])
+	}
+}
+
+func TestParseWithOptionsPreservesLargeConfigIntegers(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "cfg.json")
+	if err := os.WriteFile(configPath, []byte(`{"port": 1000000}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s configTestSchema
+	var source map[string]string
+	var err error
+
+	withArgs(t, nil, func() {
+		source, err = ParseWithOptions(&s, WithConfigFile(configPath, "json"))
+	})
+	if err != nil {
+		t.Fatalf("ParseWithOptions returned error: %v", err)
+	}
+
+	if s.Port != 1000000 || source["port"] != "config" {
+		t.Errorf("Port = %d (source %q), want 1000000 (source config)", s.Port, source["port"])
+	}
+}
+
+func TestParseWithOptionsSurfacesBadConfigValue(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "cfg.json")
+	if err := os.WriteFile(configPath, []byte(`{"port": "not-a-number"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s configTestSchema
+	var err error
+
+	withArgs(t, nil, func() {
+		_, err = ParseWithOptions(&s, WithConfigFile(configPath, "json"))
+	})
+	if err == nil {
+		t.Fatal("expected an error for a config value that fails to parse, got nil")
+	}
+}
+
+func TestWithConfigFileRejectsNonJSON(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "cfg.toml")
+	if err := os.WriteFile(configPath, []byte("[server]\nhost = \"h\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s configTestSchema
+	var err error
+
+	withArgs(t, nil, func() {
+		_, err = ParseWithOptions(&s, WithConfigFile(configPath, "toml"))
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported config format, got nil")
+	}
+}
@@ -0,0 +1,82 @@
+package flags
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// usageTemplate renders a single command's help text: its own flags (if
+// any) followed by its nested subcommands (if any). It's executed once per
+// *flag.FlagSet, through a tabwriter so flag/command columns line up.
+var usageTemplate = template.Must(template.New("usage").Parse(
+	`Usage: {{.Name}} [flags]{{if .Commands}} [command]{{end}}
+{{if .Flags}}
+Flags:
+{{range .Flags}}  -{{.Name}}{{if .Short}}, -{{.Short}}{{end}}	{{.Usage}}{{if .Default}} (default {{.Default}}){{end}}{{if .Required}} (required){{end}}
+{{end}}{{end}}{{if .Commands}}
+Commands:
+{{range .Commands}}  {{.}}
+{{end}}{{end}}`))
+
+type usageData struct {
+	Name     string
+	Flags    []flagUsage
+	Commands []string
+}
+
+type flagUsage struct {
+	Name     string
+	Short    string
+	Usage    string
+	Default  string
+	Required bool
+}
+
+// PrintUsage writes the top-level help text for the schema s to w: its
+// own flags plus the list of top-level commands. Run the program with
+// -h/--help (at the top level, or after any command) to see the same
+// output for that command - every *flag.FlagSet built by buildCommandTree
+// has its Usage wired to this same renderer.
+func PrintUsage(w io.Writer, s interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return ErrWrongType
+	}
+
+	tree := buildCommandTree(scratchCopy(v))
+	return renderUsage(w, tree[""])
+}
+
+// renderUsage is what every node's flag.FlagSet.Usage is wired to.
+func renderUsage(w io.Writer, node *commandNode) error {
+	data := usageData{Name: node.fs.Name()}
+	if data.Name == "" {
+		data.Name = os.Args[0]
+	}
+
+	for _, fb := range node.fields {
+		data.Flags = append(data.Flags, flagUsage{
+			Name:     strings.ToLower(fb.sf.Name),
+			Short:    fb.sf.Tag.Get("short"),
+			Usage:    fb.sf.Tag.Get("usage"),
+			Default:  fb.sf.Tag.Get("default"),
+			Required: fb.sf.Tag.Get("required") == "true",
+		})
+	}
+
+	for name := range node.children {
+		data.Commands = append(data.Commands, name)
+	}
+	sort.Strings(data.Commands)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if err := usageTemplate.Execute(tw, data); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
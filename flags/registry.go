@@ -0,0 +1,175 @@
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsers maps a struct field's reflect.Type to a factory that produces a
+// fresh flag.Getter for that type. IterFields consults this registry to
+// decide how to turn a field into a flag, rather than hard-coding a
+// reflect.Kind switch, so new types can be supported without touching the
+// core walking logic.
+var parsers = make(map[reflect.Type]func() flag.Getter)
+
+// RegisterParser associates a type with a factory for producing flag.Value
+// (and flag.Getter, so the parsed value can be read back out) instances for
+// that type. Call this before Parse to teach the package about a type not
+// covered by the built-in registrations below (e.g. a custom enum or ID
+// type).
+func RegisterParser(t reflect.Type, factory func() flag.Getter) {
+	parsers[t] = factory
+}
+
+// lookupParser returns the factory registered for t, if any.
+func lookupParser(t reflect.Type) (func() flag.Getter, bool) {
+	factory, ok := parsers[t]
+	return factory, ok
+}
+
+func init() {
+	RegisterParser(reflect.TypeOf(false), func() flag.Getter { return new(boolValue) })
+	RegisterParser(reflect.TypeOf(int(0)), func() flag.Getter { return new(intValue) })
+	RegisterParser(reflect.TypeOf(int64(0)), func() flag.Getter { return new(int64Value) })
+	RegisterParser(reflect.TypeOf(float64(0)), func() flag.Getter { return new(float64Value) })
+	RegisterParser(reflect.TypeOf(""), func() flag.Getter { return new(stringValue) })
+	RegisterParser(reflect.TypeOf(time.Duration(0)), func() flag.Getter { return new(durationValue) })
+	RegisterParser(reflect.TypeOf(net.IP{}), func() flag.Getter { return new(ipValue) })
+	RegisterParser(reflect.TypeOf([]string{}), func() flag.Getter { return new(stringSliceValue) })
+	RegisterParser(reflect.TypeOf(map[string]string{}), func() flag.Getter { return new(stringMapValue) })
+}
+
+// boolValue, intValue etc. below each implement flag.Value (String/Set) and
+// flag.Getter (Get), mirroring the unexported types the standard library
+// flag package keeps to itself. We need our own so Parse can read the
+// parsed value back out via Get() and assign it onto the destination
+// struct field with reflect.
+
+type boolValue bool
+
+func (b *boolValue) String() string { return strconv.FormatBool(bool(*b)) }
+func (b *boolValue) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = boolValue(v)
+	return nil
+}
+func (b *boolValue) Get() interface{} { return bool(*b) }
+
+// IsBoolFlag tells the flag package that this flag takes no argument (so
+// `-v` is valid on its own, rather than consuming the next token as its
+// value), matching the behaviour of flag.Bool/BoolVar.
+func (b *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (i *intValue) String() string { return strconv.Itoa(int(*i)) }
+func (i *intValue) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*i = intValue(v)
+	return nil
+}
+func (i *intValue) Get() interface{} { return int(*i) }
+
+type int64Value int64
+
+func (i *int64Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+func (i *int64Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64Value(v)
+	return nil
+}
+func (i *int64Value) Get() interface{} { return int64(*i) }
+
+type float64Value float64
+
+func (f *float64Value) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 64) }
+func (f *float64Value) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f = float64Value(v)
+	return nil
+}
+func (f *float64Value) Get() interface{} { return float64(*f) }
+
+type stringValue string
+
+func (s *stringValue) String() string     { return string(*s) }
+func (s *stringValue) Set(v string) error { *s = stringValue(v); return nil }
+func (s *stringValue) Get() interface{}   { return string(*s) }
+
+type durationValue time.Duration
+
+func (d *durationValue) String() string { return time.Duration(*d).String() }
+func (d *durationValue) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
+func (d *durationValue) Get() interface{} { return time.Duration(*d) }
+
+type ipValue net.IP
+
+func (ip *ipValue) String() string { return net.IP(*ip).String() }
+func (ip *ipValue) Set(s string) error {
+	parsed := net.ParseIP(s)
+	if parsed == nil {
+		return fmt.Errorf("invalid IP address: %q", s)
+	}
+	*ip = ipValue(parsed)
+	return nil
+}
+func (ip *ipValue) Get() interface{} { return net.IP(*ip) }
+
+// stringSliceValue accumulates one entry per occurrence of the flag on the
+// command line, e.g. -tag=a -tag=b -tag=c produces []string{"a", "b", "c"}.
+type stringSliceValue []string
+
+func (s *stringSliceValue) String() string { return strings.Join([]string(*s), ",") }
+func (s *stringSliceValue) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+func (s *stringSliceValue) Get() interface{} { return []string(*s) }
+
+// stringMapValue accumulates key=value pairs, one per occurrence of the
+// flag, e.g. -tag=a=1 -tag=b=2 produces map[string]string{"a": "1", "b": "2"}.
+type stringMapValue map[string]string
+
+func (m *stringMapValue) String() string {
+	pairs := make([]string, 0, len(*m))
+	for k, v := range *m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+func (m *stringMapValue) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	if *m == nil {
+		*m = make(stringMapValue)
+	}
+	(*m)[k] = v
+	return nil
+}
+func (m *stringMapValue) Get() interface{} { return map[string]string(*m) }
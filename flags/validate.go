@@ -0,0 +1,142 @@
+package flags
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidationErrors aggregates every problem Validate found in a schema, so
+// a caller gets one readable report instead of discovering mistakes one
+// reflect panic at a time.
+type ValidationErrors struct {
+	Errs []error
+}
+
+func (v *ValidationErrors) Error() string {
+	msgs := make([]string, len(v.Errs))
+	for i, err := range v.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("flags: invalid schema:\n  - %s", strings.Join(msgs, "\n  - "))
+}
+
+// Validate walks s (which must be a pointer to a struct, same as Parse)
+// and reports every schema mistake it can find before any reflection-heavy
+// flag building happens:
+//
+//   - a tagged field (short/usage/env/default) that is unexported, so the
+//     tags can never take effect
+//   - a long or short flag name used more than once at the same command
+//     level (each command gets its own *flag.FlagSet, so the same name can
+//     be reused across sibling/unrelated commands without colliding - e.g.
+//     `remote add --verbose` and `remote remove --verbose` are both fine)
+//   - a `short` tag longer than one rune
+//   - a field type with no parser registered (see RegisterParser)
+//   - a command name that collides with a flag name at the same level
+//
+// Parse calls Validate itself, so most callers never need to call it
+// directly; it's exported so a schema can be checked ahead of time, e.g. in
+// a test.
+func Validate(s interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return ErrWrongType
+	}
+
+	ctx := &validation{}
+	validateFields(v.Type(), "", make(map[reflect.Type]bool), ctx)
+
+	if len(ctx.errs) == 0 {
+		return nil
+	}
+	return &ValidationErrors{Errs: ctx.errs}
+}
+
+// validation carries the errors accumulated while Validate walks the tree.
+type validation struct {
+	errs []error
+}
+
+func validateFields(st reflect.Type, path string, seen map[reflect.Type]bool, ctx *validation) {
+	// levelNames tracks every command and flag name introduced at this one
+	// command level (not the whole tree), since that's the scope a single
+	// *flag.FlagSet actually has to be collision-free in.
+	levelNames := make(map[string]string)
+
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+
+		hasTags := sf.Tag.Get("short") != "" || sf.Tag.Get("usage") != "" ||
+			sf.Tag.Get("env") != "" || sf.Tag.Get("default") != ""
+
+		if sf.PkgPath != "" {
+			// unexported: reflect can never set it, so any tags on it are dead.
+			if hasTags {
+				ctx.errs = append(ctx.errs, fmt.Errorf("%s: field %q is unexported but has flag tags", label(path), sf.Name))
+			}
+			continue
+		}
+
+		ft := sf.Type
+		isPtr := ft.Kind() == reflect.Ptr
+		underlying := ft
+		if isPtr {
+			underlying = ft.Elem()
+		}
+
+		if underlying.Kind() == reflect.Struct {
+			name := strings.ToLower(sf.Name)
+			checkLevelCollision(ctx, levelNames, name, fmt.Sprintf("command %q", sf.Name), path)
+
+			if seen[underlying] {
+				continue // cycle: already validated on the way down this branch
+			}
+
+			childPath := name
+			if path != "" {
+				childPath = path + "." + name
+			}
+
+			seen[underlying] = true
+			validateFields(underlying, childPath, seen, ctx)
+			delete(seen, underlying)
+			continue
+		}
+
+		name := strings.ToLower(sf.Name)
+		short := sf.Tag.Get("short")
+
+		checkLevelCollision(ctx, levelNames, name, fmt.Sprintf("field %q", sf.Name), path)
+
+		if _, ok := lookupParser(ft); !ok {
+			ctx.errs = append(ctx.errs, fmt.Errorf("%s: field %q has unsupported type %s (no parser registered, see RegisterParser)", label(path), sf.Name, ft))
+		}
+
+		if len([]rune(short)) > 1 {
+			ctx.errs = append(ctx.errs, fmt.Errorf("%s: field %q has short tag %q longer than one rune", label(path), sf.Name, short))
+		}
+
+		if short != "" {
+			checkLevelCollision(ctx, levelNames, short, fmt.Sprintf("field %q (short)", sf.Name), path)
+		}
+	}
+}
+
+// checkLevelCollision records that name is introduced at path by desc, and
+// reports an error if some other field or command at the same level already
+// claimed that name.
+func checkLevelCollision(ctx *validation, levelNames map[string]string, name, desc, path string) {
+	if prev, ok := levelNames[name]; ok {
+		ctx.errs = append(ctx.errs, fmt.Errorf("%s: %q is used by both %s and %s", label(path), name, prev, desc))
+		return
+	}
+	levelNames[name] = desc
+}
+
+func label(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return "command " + path
+}
@@ -0,0 +1,44 @@
+package flags
+
+import "testing"
+
+func TestValidateAllowsSameShortNameInSiblingCommands(t *testing.T) {
+	type Add struct {
+		Verbose bool `short:"v" usage:"verbose"`
+	}
+	type Remove struct {
+		Verbose bool `short:"v" usage:"verbose"`
+	}
+	type Schema struct {
+		Add    Add
+		Remove Remove
+	}
+
+	if err := Validate(&Schema{}); err != nil {
+		t.Fatalf("Validate rejected sibling commands reusing a flag name: %v", err)
+	}
+}
+
+func TestValidateRejectsDuplicateNameAtSameLevel(t *testing.T) {
+	type Schema struct {
+		Verbose bool `short:"v" usage:"verbose"`
+		Version bool `short:"v" usage:"version"`
+	}
+
+	if err := Validate(&Schema{}); err == nil {
+		t.Fatal("Validate accepted two flags sharing a short name at the same level")
+	}
+}
+
+func TestValidateRejectsCommandFlagCollisionAtSameLevel(t *testing.T) {
+	type Foo struct{}
+	type Schema struct {
+		Foo  Foo
+		FOO  bool `usage:"collides with the command above"`
+		Keep int  `usage:"unrelated"`
+	}
+
+	if err := Validate(&Schema{}); err == nil {
+		t.Fatal("Validate accepted a command and a flag sharing a name at the same level")
+	}
+}